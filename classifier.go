@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gocv.io/x/gocv"
+)
+
+// Classification is one object found within a motion rect.
+type Classification struct {
+	Rect       image.Rectangle
+	Label      string
+	Confidence float32
+}
+
+// Classifier gates motion rects behind an object classification, so a
+// deployment can ignore motion that isn't, say, a person or a car.
+// Classify returns only the rects that passed the whitelist/confidence
+// check, paired with what they were classified as.
+type Classifier interface {
+	Classify(img gocv.Mat, rects []image.Rectangle) []Classification
+	Close() error
+}
+
+// NoopClassifier is the default: every rect passes through labeled
+// "motion", so a Watcher with no configured model behaves exactly like
+// one with no classifier gate at all.
+type NoopClassifier struct{}
+
+func (NoopClassifier) Classify(_ gocv.Mat, rects []image.Rectangle) []Classification {
+	out := make([]Classification, len(rects))
+	for i, r := range rects {
+		out[i] = Classification{Rect: r, Label: "motion", Confidence: 1}
+	}
+	return out
+}
+
+func (NoopClassifier) Close() error { return nil }
+
+// ClassifierConfig describes an ONNX (or other OpenCV DNN-readable)
+// object classifier.
+type ClassifierConfig struct {
+	// ModelPath is a file gocv.ReadNet can load. It must be a plain
+	// classifier export (a single 1xN per-class score row) — not a
+	// detection export like MobileNet-SSD or YOLOv5n, which ONNXClassifier
+	// can't decode yet. Leaving this empty disables classification.
+	ModelPath string `json:"modelPath" yaml:"modelPath"`
+
+	// Labels are the model's output classes, in output-index order.
+	Labels []string `json:"labels" yaml:"labels"`
+
+	// Whitelist restricts which labels are allowed to confirm motion,
+	// e.g. []string{"person", "car", "dog"}.
+	Whitelist []string `json:"whitelist" yaml:"whitelist"`
+
+	// ConfidenceThreshold is the minimum softmax/sigmoid score, 0-1, for
+	// a classification to count.
+	ConfidenceThreshold float32 `json:"confidenceThreshold" yaml:"confidenceThreshold"`
+
+	// InputSize is the square size, in pixels, the model expects its
+	// input blob resized to.
+	InputSize int `json:"inputSize" yaml:"inputSize"`
+}
+
+const (
+	DefaultClassifierConfidenceThreshold = 0.5
+	DefaultClassifierInputSize           = 300
+)
+
+// LoadClassifierConfig reads a ClassifierConfig from a YAML or JSON file,
+// chosen by the file's extension.
+func LoadClassifierConfig(path string) (*ClassifierConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading classifier config %v: %w", path, err)
+	}
+
+	cfg := &ClassifierConfig{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing classifier config %v: %w", path, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing classifier config %v: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("classifier config %v: unrecognized extension %v", path, filepath.Ext(path))
+	}
+
+	return cfg, nil
+}
+
+// NewClassifier builds the Classifier described by cfg. A nil cfg, or one
+// with no ModelPath, yields a NoopClassifier.
+func NewClassifier(cfg *ClassifierConfig) (Classifier, error) {
+	if cfg == nil || cfg.ModelPath == "" {
+		return NoopClassifier{}, nil
+	}
+	return NewONNXClassifier(cfg)
+}
+
+// ONNXClassifier runs each motion rect through an ONNX-exported network
+// loaded via gocv's DNN module. It expects a plain classifier export
+// whose output is a single 1xN row of per-class scores (topClass reads
+// it via MinMaxLoc) — not a detection export like MobileNet-SSD or
+// YOLOv5n, whose output tensors encode boxes/objectness per grid cell
+// and would need their own decode step before Classify's whitelist gate
+// means anything.
+type ONNXClassifier struct {
+	cfg *ClassifierConfig
+	net gocv.Net
+
+	whitelist map[string]bool
+}
+
+func NewONNXClassifier(cfg *ClassifierConfig) (*ONNXClassifier, error) {
+	net := gocv.ReadNet(cfg.ModelPath, "")
+	if net.Empty() {
+		return nil, fmt.Errorf("reading classifier model %v", cfg.ModelPath)
+	}
+
+	whitelist := make(map[string]bool, len(cfg.Whitelist))
+	for _, label := range cfg.Whitelist {
+		whitelist[label] = true
+	}
+
+	if cfg.ConfidenceThreshold == 0 {
+		cfg.ConfidenceThreshold = DefaultClassifierConfidenceThreshold
+	}
+	if cfg.InputSize == 0 {
+		cfg.InputSize = DefaultClassifierInputSize
+	}
+
+	return &ONNXClassifier{
+		cfg:       cfg,
+		net:       net,
+		whitelist: whitelist,
+	}, nil
+}
+
+func (c *ONNXClassifier) Classify(img gocv.Mat, rects []image.Rectangle) []Classification {
+	var out []Classification
+
+	for _, rect := range rects {
+		roi := img.Region(rect)
+
+		blob := gocv.BlobFromImage(roi, 1.0/255.0,
+			image.Pt(c.cfg.InputSize, c.cfg.InputSize),
+			gocv.NewScalar(0, 0, 0, 0), true, false)
+
+		c.net.SetInput(blob, "")
+		output := c.net.Forward("")
+
+		label, confidence := topClass(output, c.cfg.Labels)
+
+		blob.Close()
+		output.Close()
+		roi.Close()
+
+		if confidence < c.cfg.ConfidenceThreshold {
+			continue
+		}
+		if len(c.whitelist) > 0 && !c.whitelist[label] {
+			continue
+		}
+
+		out = append(out, Classification{Rect: rect, Label: label, Confidence: confidence})
+	}
+
+	return out
+}
+
+func (c *ONNXClassifier) Close() error { return c.net.Close() }
+
+// topClass reads output as a 1xN row of per-class scores and returns the
+// highest-scoring label.
+func topClass(output gocv.Mat, labels []string) (string, float32) {
+	_, maxVal, _, maxLoc := gocv.MinMaxLoc(output)
+
+	label := "unknown"
+	if maxLoc.X >= 0 && maxLoc.X < len(labels) {
+		label = labels[maxLoc.X]
+	}
+
+	return label, maxVal
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"gocv.io/x/gocv"
+)
+
+// Detector finds the regions of a frame that look like motion. Swapping
+// implementations lets a deployment pick the CV pipeline that fits its
+// scene (MOG2 background subtraction vs. a simple frame-diff) without
+// touching the Watcher state machine.
+type Detector interface {
+	// Detect returns the bounding rects of whatever looks like motion in
+	// img. img is not mutated.
+	Detect(img gocv.Mat) []image.Rectangle
+
+	// DebugMats returns the detector's intermediate stages by name (e.g.
+	// "delta", "thresh", "mask") so each can be served on its own MJPEG
+	// endpoint for live tuning.
+	DebugMats() map[string]gocv.Mat
+
+	Close() error
+}
+
+// DetectorConfig is the on-disk, user-tunable shape of a Detector. It's
+// loaded from YAML or JSON via LoadDetectorConfig.
+type DetectorConfig struct {
+	// Kind selects the implementation: "mog2" or "framediff".
+	Kind string `json:"kind" yaml:"kind"`
+
+	// Threshold is the binary threshold applied to the foreground mask, 0-255.
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+
+	// MinAreaFraction is the minimum contour area, as a fraction of the
+	// frame's total area, to be considered motion.
+	MinAreaFraction float64 `json:"minAreaFraction" yaml:"minAreaFraction"`
+
+	// DilateKernelSize is the width/height, in pixels, of the square
+	// structuring element used to dilate the threshold mask.
+	DilateKernelSize int `json:"dilateKernelSize" yaml:"dilateKernelSize"`
+
+	// LearningRate is currently unused by every detector: gocv's MOG2
+	// binding doesn't expose a per-call learning rate, so there's nothing
+	// for MOG2Detector to pass it to either. Reserved for when it does.
+	LearningRate float64 `json:"learningRate" yaml:"learningRate"`
+
+	// ROI is an optional polygon, in frame pixel coordinates, outside of
+	// which motion is ignored (e.g. to mask off a road or tree line).
+	ROI []image.Point `json:"roi" yaml:"roi"`
+}
+
+const (
+	DefaultThreshold        = 25
+	DefaultMinAreaFraction  = 3000.0 / (DefaultWidth * DefaultHeight)
+	DefaultDilateKernelSize = 3
+	DefaultLearningRate     = -1 // gocv default: let MOG2 pick its own rate
+)
+
+func DefaultDetectorConfig() *DetectorConfig {
+	return &DetectorConfig{
+		Kind:             "mog2",
+		Threshold:        DefaultThreshold,
+		MinAreaFraction:  DefaultMinAreaFraction,
+		DilateKernelSize: DefaultDilateKernelSize,
+		LearningRate:     DefaultLearningRate,
+	}
+}
+
+// LoadDetectorConfig reads a DetectorConfig from a YAML or JSON file,
+// chosen by the file's extension.
+func LoadDetectorConfig(path string) (*DetectorConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading detector config %v: %w", path, err)
+	}
+
+	cfg := DefaultDetectorConfig()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing detector config %v: %w", path, err)
+		}
+	case ".json":
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing detector config %v: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("detector config %v: unrecognized extension %v", path, filepath.Ext(path))
+	}
+
+	return cfg, nil
+}
+
+// NewDetector builds the Detector described by cfg.
+func NewDetector(cfg *DetectorConfig) (Detector, error) {
+	var d Detector
+	switch cfg.Kind {
+	case "", "mog2":
+		d = NewMOG2Detector(cfg)
+	case "framediff":
+		d = NewFrameDiffDetector(cfg)
+	default:
+		return nil, fmt.Errorf("unknown detector kind %q", cfg.Kind)
+	}
+
+	if len(cfg.ROI) > 0 {
+		d = NewROIDetector(d, cfg.ROI)
+	}
+
+	return d, nil
+}
+
+func minArea(img gocv.Mat, fraction float64) float64 {
+	return float64(img.Rows()*img.Cols()) * fraction
+}
+
+func contoursToRects(shapes [][]image.Point, minArea float64) []image.Rectangle {
+	var rects []image.Rectangle
+	for _, shape := range shapes {
+		if gocv.ContourArea(shape) < minArea {
+			continue
+		}
+		rects = append(rects, gocv.BoundingRect(shape))
+	}
+	return rects
+}
+
+// MOG2Detector is a background-subtraction detector: it learns a model of
+// the static scene and flags pixels that don't fit it.
+type MOG2Detector struct {
+	cfg *DetectorConfig
+
+	mog2 gocv.BackgroundSubtractorMOG2
+
+	imgDelta, imgThresh gocv.Mat
+}
+
+func NewMOG2Detector(cfg *DetectorConfig) *MOG2Detector {
+	return &MOG2Detector{
+		cfg:       cfg,
+		mog2:      gocv.NewBackgroundSubtractorMOG2(),
+		imgDelta:  gocv.NewMat(),
+		imgThresh: gocv.NewMat(),
+	}
+}
+
+func (d *MOG2Detector) Detect(img gocv.Mat) []image.Rectangle {
+	// NOTE: gocv's MOG2 binding doesn't expose a per-call learning rate,
+	// so cfg.LearningRate is reserved for when it does.
+	d.mog2.Apply(img, &d.imgDelta)
+
+	gocv.Threshold(d.imgDelta, &d.imgThresh, float32(d.cfg.Threshold), 255, gocv.ThresholdBinary)
+
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(d.cfg.DilateKernelSize, d.cfg.DilateKernelSize))
+	defer kernel.Close()
+	gocv.Dilate(d.imgThresh, &d.imgThresh, kernel)
+
+	shapes := gocv.FindContours(d.imgThresh, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	return contoursToRects(shapes, minArea(img, d.cfg.MinAreaFraction))
+}
+
+func (d *MOG2Detector) DebugMats() map[string]gocv.Mat {
+	return map[string]gocv.Mat{
+		"delta":  d.imgDelta,
+		"thresh": d.imgThresh,
+	}
+}
+
+func (d *MOG2Detector) Close() error {
+	d.imgDelta.Close()
+	d.imgThresh.Close()
+	return d.mog2.Close()
+}
+
+// FrameDiffDetector is the classic delta-from-a-fixed-reference-frame
+// approach: the first frame seen becomes the reference and every later
+// frame is diffed against it.
+type FrameDiffDetector struct {
+	cfg *DetectorConfig
+
+	reference           gocv.Mat
+	imgDelta, imgThresh gocv.Mat
+}
+
+func NewFrameDiffDetector(cfg *DetectorConfig) *FrameDiffDetector {
+	return &FrameDiffDetector{
+		cfg:       cfg,
+		reference: gocv.NewMat(),
+		imgDelta:  gocv.NewMat(),
+		imgThresh: gocv.NewMat(),
+	}
+}
+
+func (d *FrameDiffDetector) Detect(img gocv.Mat) []image.Rectangle {
+	if d.reference.Empty() {
+		img.CopyTo(&d.reference)
+		return nil
+	}
+
+	gocv.AbsDiff(d.reference, img, &d.imgDelta)
+	gocv.CvtColor(d.imgDelta, &d.imgDelta, gocv.ColorBGRToGray)
+
+	gocv.Threshold(d.imgDelta, &d.imgThresh, float32(d.cfg.Threshold), 255, gocv.ThresholdBinary)
+
+	kernel := gocv.GetStructuringElement(gocv.MorphRect, image.Pt(d.cfg.DilateKernelSize, d.cfg.DilateKernelSize))
+	defer kernel.Close()
+	gocv.Dilate(d.imgThresh, &d.imgThresh, kernel)
+
+	shapes := gocv.FindContours(d.imgThresh, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	return contoursToRects(shapes, minArea(img, d.cfg.MinAreaFraction))
+}
+
+func (d *FrameDiffDetector) DebugMats() map[string]gocv.Mat {
+	return map[string]gocv.Mat{
+		"delta":  d.imgDelta,
+		"thresh": d.imgThresh,
+	}
+}
+
+func (d *FrameDiffDetector) Close() error {
+	d.reference.Close()
+	d.imgDelta.Close()
+	return d.imgThresh.Close()
+}
+
+// ROIDetector wraps another Detector and drops any rect whose center
+// falls outside a region-of-interest polygon, so users can mask off
+// known-noisy areas like trees or a road.
+type ROIDetector struct {
+	Detector
+
+	mask gocv.Mat
+	roi  []image.Point
+}
+
+func NewROIDetector(inner Detector, roi []image.Point) *ROIDetector {
+	return &ROIDetector{
+		Detector: inner,
+		roi:      roi,
+		mask:     gocv.NewMat(),
+	}
+}
+
+func (d *ROIDetector) Detect(img gocv.Mat) []image.Rectangle {
+	rects := d.Detector.Detect(img)
+
+	if d.mask.Empty() {
+		d.mask = gocv.NewMatWithSize(img.Rows(), img.Cols(), gocv.MatTypeCV8U)
+		gocv.FillPoly(&d.mask, [][]image.Point{d.roi}, color.RGBA{255, 255, 255, 0})
+	}
+
+	var inROI []image.Rectangle
+	for _, r := range rects {
+		center := image.Pt(r.Min.X+r.Dx()/2, r.Min.Y+r.Dy()/2)
+		if pointInPolygon(center, d.roi) {
+			inROI = append(inROI, r)
+		}
+	}
+	return inROI
+}
+
+func (d *ROIDetector) DebugMats() map[string]gocv.Mat {
+	mats := d.Detector.DebugMats()
+	mats["mask"] = d.mask
+	return mats
+}
+
+func (d *ROIDetector) Close() error {
+	d.mask.Close()
+	return d.Detector.Close()
+}
+
+// pointInPolygon is a standard even-odd ray casting test.
+func pointInPolygon(p image.Point, polygon []image.Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		pi, pj := polygon[i], polygon[j]
+		if (pi.Y > p.Y) != (pj.Y > p.Y) &&
+			p.X < (pj.X-pi.X)*(p.Y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// EventType identifies a WatcherKernel state transition or lifecycle
+// event that other systems (Home Assistant, Node-RED, a NAS) might care
+// about.
+type EventType string
+
+const (
+	EventWatchingToMotion    EventType = "watching->motion"
+	EventMotionToRecording   EventType = "motion->recording"
+	EventRecordingToWatching EventType = "recording->watching"
+	EventRecordingFinalized  EventType = "recording.finalized"
+)
+
+// Event is the payload published on every state transition.
+type Event struct {
+	Type      EventType         `json:"type"`
+	Timestamp time.Time         `json:"timestamp"`
+	Boxes     []image.Rectangle `json:"boxes,omitempty"`
+	Snapshot  []byte            `json:"snapshot,omitempty"`
+
+	// Filename and Duration are only set on EventRecordingFinalized.
+	Filename string        `json:"filename,omitempty"`
+	Duration time.Duration `json:"durationNs,omitempty"`
+}
+
+// EventSubscriber receives every Event published to an EventBus. Publish
+// is called on its own goroutine per event, so a slow subscriber (a flaky
+// webhook endpoint, say) never blocks the state machine.
+type EventSubscriber interface {
+	Publish(Event)
+}
+
+// EventBus fans state-transition events out to any number of
+// EventSubscribers. It's the extension point: adding a new notification
+// channel means writing an EventSubscriber, not touching the state
+// machine.
+type EventBus struct {
+	subscribers []EventSubscriber
+}
+
+func NewEventBus(subscribers ...EventSubscriber) *EventBus {
+	return &EventBus{subscribers: subscribers}
+}
+
+func (b *EventBus) Publish(e Event) {
+	for _, s := range b.subscribers {
+		go s.Publish(e)
+	}
+}
+
+const (
+	DefaultWebhookRetries    = 3
+	DefaultWebhookRetryDelay = 2 * time.Second
+)
+
+// WebhookSubscriber POSTs every Event as JSON to a configured URL, HMAC
+// signing the body so the receiver can verify it came from this watcher.
+type WebhookSubscriber struct {
+	URL        string
+	Secret     string
+	MaxRetries int
+	RetryDelay time.Duration
+
+	client *http.Client
+}
+
+func NewWebhookSubscriber(url, secret string) *WebhookSubscriber {
+	return &WebhookSubscriber{
+		URL:        url,
+		Secret:     secret,
+		MaxRetries: DefaultWebhookRetries,
+		RetryDelay: DefaultWebhookRetryDelay,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *WebhookSubscriber) Publish(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Print("webhook: marshaling event: ", err)
+		return
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= s.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(s.RetryDelay * time.Duration(attempt))
+		}
+
+		if lastErr = s.post(body); lastErr == nil {
+			return
+		}
+	}
+
+	log.Printf("webhook: giving up after %d attempts: %v", s.MaxRetries+1, lastErr)
+}
+
+func (s *WebhookSubscriber) post(body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Secret != "" {
+		req.Header.Set("X-Watcher-Signature", s.sign(body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %v: unexpected status %v", s.URL, resp.Status)
+	}
+	return nil
+}
+
+func (s *WebhookSubscriber) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.Secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// MQTTSubscriber publishes every Event as JSON to an MQTT broker, on a
+// topic scoped to a single camera so Home Assistant/Node-RED can
+// subscribe per-device.
+type MQTTSubscriber struct {
+	client   mqtt.Client
+	topic    string
+	qos      byte
+	retained bool
+}
+
+// NewMQTTSubscriber connects to broker (e.g. "tcp://localhost:1883") and
+// publishes under "watcher/<cameraID>/event".
+func NewMQTTSubscriber(broker, cameraID string, qos byte) (*MQTTSubscriber, error) {
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("watcher-%s", cameraID)).
+		SetAutoReconnect(true)
+
+	client := mqtt.NewClient(opts)
+	if token := client.Connect(); token.Wait() && token.Error() != nil {
+		return nil, fmt.Errorf("connecting to mqtt broker %v: %w", broker, token.Error())
+	}
+
+	return &MQTTSubscriber{
+		client: client,
+		topic:  fmt.Sprintf("watcher/%s/event", cameraID),
+		qos:    qos,
+	}, nil
+}
+
+func (s *MQTTSubscriber) Publish(e Event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		log.Print("mqtt: marshaling event: ", err)
+		return
+	}
+
+	token := s.client.Publish(s.topic, s.qos, s.retained, body)
+	token.Wait()
+	if err := token.Error(); err != nil {
+		log.Print("mqtt: publishing event: ", err)
+	}
+}
+
+func (s *MQTTSubscriber) Close() error {
+	s.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// DefaultFrameBufferSize bounds how many captured frames can queue up
+	// before the capture goroutine starts dropping the oldest one.
+	DefaultFrameBufferSize = 8
+
+	// DefaultMotionDetectInterval samples every Nth frame the Watcher reads
+	// off the frame buffer (frames dropped there aren't counted) for
+	// detection, so a slow detector doesn't also slow down capture.
+	DefaultMotionDetectInterval = 2
+
+	DefaultEncodeQueueSize = 4
+	DefaultWriteQueueSize  = 32
+)
+
+// Frame is one captured image paired with when it was read.
+type Frame struct {
+	Mat        gocv.Mat
+	CapturedAt time.Time
+}
+
+func (f Frame) Close() error { return f.Mat.Close() }
+
+// Stats is a point-in-time snapshot of the pipeline's backpressure,
+// served on /stats so a slow detector or encoder shows up as a metric
+// instead of only as a stalled debug stream.
+type Stats struct {
+	DroppedFrames  uint64        `json:"droppedFrames"`
+	QueueDepth     int           `json:"queueDepth"`
+	CaptureLatency time.Duration `json:"captureLatencyNs"`
+	DetectLatency  time.Duration `json:"detectLatencyNs"`
+	EncodeLatency  time.Duration `json:"encodeLatencyNs"`
+	WriteLatency   time.Duration `json:"writeLatencyNs"`
+}
+
+// statsRecorder holds the same fields as Stats behind atomics so the
+// capture, detect, encode, and write goroutines can update it
+// concurrently without a mutex.
+type statsRecorder struct {
+	dropped        uint64
+	queueDepth     int64
+	captureLatency int64
+	detectLatency  int64
+	encodeLatency  int64
+	writeLatency   int64
+}
+
+func (s *statsRecorder) recordDrop()                   { atomic.AddUint64(&s.dropped, 1) }
+func (s *statsRecorder) setQueueDepth(n int)           { atomic.StoreInt64(&s.queueDepth, int64(n)) }
+func (s *statsRecorder) recordCapture(d time.Duration) { atomic.StoreInt64(&s.captureLatency, int64(d)) }
+func (s *statsRecorder) recordDetect(d time.Duration)  { atomic.StoreInt64(&s.detectLatency, int64(d)) }
+func (s *statsRecorder) recordEncode(d time.Duration)  { atomic.StoreInt64(&s.encodeLatency, int64(d)) }
+func (s *statsRecorder) recordWrite(d time.Duration)   { atomic.StoreInt64(&s.writeLatency, int64(d)) }
+
+func (s *statsRecorder) Snapshot() Stats {
+	return Stats{
+		DroppedFrames:  atomic.LoadUint64(&s.dropped),
+		QueueDepth:     int(atomic.LoadInt64(&s.queueDepth)),
+		CaptureLatency: time.Duration(atomic.LoadInt64(&s.captureLatency)),
+		DetectLatency:  time.Duration(atomic.LoadInt64(&s.detectLatency)),
+		EncodeLatency:  time.Duration(atomic.LoadInt64(&s.encodeLatency)),
+		WriteLatency:   time.Duration(atomic.LoadInt64(&s.writeLatency)),
+	}
+}
+
+// StatsHandler serves the pipeline's current Stats as JSON.
+func (s *statsRecorder) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(s.Snapshot())
+	})
+}
+
+// CapturePipeline decouples frame capture from everything downstream: one
+// goroutine reads frames from src as fast as the device allows into a
+// bounded ring buffer, dropping the oldest frame on overflow rather than
+// letting a slow consumer throttle the capture rate.
+type CapturePipeline struct {
+	src    Source
+	frames chan Frame
+	stats  *statsRecorder
+}
+
+func NewCapturePipeline(src Source, stats *statsRecorder) *CapturePipeline {
+	return &CapturePipeline{
+		src:    src,
+		frames: make(chan Frame, DefaultFrameBufferSize),
+		stats:  stats,
+	}
+}
+
+// Run reads frames until ctx is done or the source errs.
+func (p *CapturePipeline) Run(ctx context.Context) error {
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		img := gocv.NewMat()
+		capturedAt := time.Now()
+		if ok := p.src.Read(&img); !ok {
+			img.Close()
+			return ErrReadDevice
+		}
+		if img.Empty() {
+			img.Close()
+			continue
+		}
+		p.stats.recordCapture(time.Since(capturedAt))
+
+		select {
+		case p.frames <- Frame{Mat: img, CapturedAt: capturedAt}:
+		default:
+			select {
+			case old := <-p.frames:
+				old.Close()
+				p.stats.recordDrop()
+			default:
+			}
+			p.frames <- Frame{Mat: img, CapturedAt: capturedAt}
+		}
+		p.stats.setQueueDepth(len(p.frames))
+	}
+}
+
+func (p *CapturePipeline) Frames() <-chan Frame { return p.frames }
+
+// encodeJob is a self-contained snapshot of everything one encoder pass
+// needs: the debug frame plus a clone of every detector stage Mat, taken
+// on the detection goroutine so the encoder never touches a Mat the
+// detector is concurrently writing to.
+type encodeJob struct {
+	debug  gocv.Mat
+	stages map[string]gocv.Mat
+}
+
+func (j encodeJob) Close() error {
+	j.debug.Close()
+	for _, mat := range j.stages {
+		mat.Close()
+	}
+	return nil
+}
+
+// queueLatest sends job on ch without ever blocking: when ch is full, it
+// drops the oldest waiting job to make room. It's how the
+// encoder goroutine stays decoupled from the producer without building
+// up an unbounded backlog.
+func queueLatest(ch chan encodeJob, job encodeJob, stats *statsRecorder) {
+	select {
+	case ch <- job:
+	default:
+		select {
+		case old := <-ch:
+			old.Close()
+			stats.recordDrop()
+		default:
+		}
+		ch <- job
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Sink receives the frames of a single motion event. Several Sinks can be
+// attached to the same event so, e.g., an archived MP4 and a live HLS
+// stream are produced from one shared frame feed.
+type Sink interface {
+	Write(img gocv.Mat) error
+	Close() error
+}
+
+// MultiSink fans writes out to every attached Sink. A write error from one
+// Sink is logged but doesn't stop the others from receiving the frame.
+type MultiSink struct {
+	sinks []Sink
+}
+
+func NewMultiSink(sinks ...Sink) *MultiSink {
+	return &MultiSink{sinks: sinks}
+}
+
+func (m *MultiSink) Write(img gocv.Mat) error {
+	var e error
+	for _, s := range m.sinks {
+		if err := s.Write(img); err != nil {
+			log.Print("sink write: ", err)
+			e = err
+		}
+	}
+	return e
+}
+
+func (m *MultiSink) Close() error {
+	var e error
+	for _, s := range m.sinks {
+		if err := s.Close(); err != nil {
+			log.Print("sink close: ", err)
+			e = err
+		}
+	}
+	return e
+}
+
+// MP4Sink writes frames to a single archived .mp4 file per motion event.
+type MP4Sink struct {
+	file *gocv.VideoWriter
+}
+
+func NewMP4Sink(filename string, fps float64, width, height int) (*MP4Sink, error) {
+	file, err := gocv.VideoWriterFile(filename, "avc1", fps, width, height, true)
+	if err != nil {
+		return nil, fmt.Errorf("opening mp4 sink %v: %w", filename, err)
+	}
+	return &MP4Sink{file: file}, nil
+}
+
+func (s *MP4Sink) Write(img gocv.Mat) error { return s.file.Write(img) }
+
+func (s *MP4Sink) Close() error { return s.file.Close() }
+
+const (
+	DefaultHLSSegmentDuration = 2 * time.Second
+	DefaultHLSPlaylistSize    = 5
+)
+
+// HLSSink muxes frames into a rolling HLS playlist so a motion event can be
+// watched live with low latency, not just after the fact. It shells out to
+// ffmpeg for segmenting, the same way main.go shells out to "open" to
+// launch a browser.
+type HLSSink struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	width  int
+	height int
+}
+
+// NewHLSSink starts an ffmpeg process that reads raw BGR frames on stdin
+// and writes dir/index.m3u8 plus its .ts segments.
+func NewHLSSink(dir string, fps float64, width, height int) (*HLSSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating hls dir %v: %w", dir, err)
+	}
+
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-f", "rawvideo",
+		"-pixel_format", "bgr24",
+		"-video_size", fmt.Sprintf("%dx%d", width, height),
+		"-framerate", fmt.Sprintf("%v", fps),
+		"-i", "-",
+		"-c:v", "libx264",
+		"-f", "hls",
+		"-hls_time", fmt.Sprintf("%v", DefaultHLSSegmentDuration.Seconds()),
+		"-hls_list_size", fmt.Sprint(DefaultHLSPlaylistSize),
+		"-hls_flags", "delete_segments",
+		filepath.Join(dir, "index.m3u8"),
+	)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("opening hls sink stdin: %w", err)
+	}
+	cmd.Stderr = log.Writer()
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting hls sink ffmpeg: %w", err)
+	}
+
+	return &HLSSink{
+		cmd:    cmd,
+		stdin:  stdin,
+		width:  width,
+		height: height,
+	}, nil
+}
+
+// Write feeds ffmpeg one raw BGR24 frame. Mat.ToBytes is already packed in
+// that layout, so no extra encoding step is needed.
+func (s *HLSSink) Write(img gocv.Mat) error {
+	_, err := s.stdin.Write(img.ToBytes())
+	return err
+}
+
+func (s *HLSSink) Close() error {
+	if err := s.stdin.Close(); err != nil {
+		return err
+	}
+	return s.cmd.Wait()
+}
+
+// PreRollBuffer keeps a rolling window of the last N frames seen so they
+// can be prepended to a recording once motion is confirmed, rather than
+// starting the clip at the exact moment motionDetectedAt fires.
+type PreRollBuffer struct {
+	frames   []gocv.Mat
+	capacity int
+	next     int
+	filled   bool
+}
+
+// NewPreRollBuffer keeps enough frames to cover duration at cycle frequency.
+func NewPreRollBuffer(duration time.Duration, cycle time.Duration) *PreRollBuffer {
+	capacity := int(duration / cycle)
+	if capacity < 1 {
+		capacity = 1
+	}
+	frames := make([]gocv.Mat, capacity)
+	for i := range frames {
+		frames[i] = gocv.NewMat()
+	}
+	return &PreRollBuffer{
+		frames:   frames,
+		capacity: capacity,
+	}
+}
+
+// Push copies img into the ring, overwriting the oldest frame.
+func (b *PreRollBuffer) Push(img gocv.Mat) {
+	img.CopyTo(&b.frames[b.next])
+
+	b.next = (b.next + 1) % b.capacity
+	if b.next == 0 {
+		b.filled = true
+	}
+}
+
+// Frames returns the buffered frames in capture order, oldest first.
+func (b *PreRollBuffer) Frames() []gocv.Mat {
+	if !b.filled {
+		return b.frames[:b.next]
+	}
+
+	ordered := make([]gocv.Mat, 0, b.capacity)
+	ordered = append(ordered, b.frames[b.next:]...)
+	ordered = append(ordered, b.frames[:b.next]...)
+	return ordered
+}
+
+func (b *PreRollBuffer) Close() error {
+	var e error
+	for i := range b.frames {
+		if err := b.frames[i].Close(); err != nil {
+			e = err
+		}
+	}
+	return e
+}
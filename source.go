@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+// Source abstracts wherever frames come from, so Watcher doesn't care if
+// it's reading a local device, a video file, or a network stream.
+type Source interface {
+	// Read decodes the next frame into img. It returns false when no
+	// frame could be produced (EOF, dropped connection, etc).
+	Read(img *gocv.Mat) bool
+
+	// Info reports the capture's negotiated dimensions and frame rate.
+	Info() (width, height, fps int)
+
+	Close() error
+}
+
+// TimestampedSource is implemented by Sources that can report when their
+// most recent frame was read. RTSPSource reports the wall-clock time at
+// which Read got the frame; the camera's own capture time is not used.
+type TimestampedSource interface {
+	Source
+	Timestamp() time.Time
+}
+
+// NewSource opens a Source for path, dispatching on its scheme:
+//
+//	v4l2://0          local device, index 0
+//	rtsp://host/...    RTSP network camera
+//	anything else      treated as a video file path
+func NewSource(path string, width, height int) (Source, error) {
+	switch {
+	case strings.HasPrefix(path, "v4l2://"):
+		index, err := strconv.Atoi(strings.TrimPrefix(path, "v4l2://"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid v4l2 device index %q: %w", path, err)
+		}
+		return NewDeviceSource(index, width, height)
+
+	case strings.HasPrefix(path, "rtsp://"):
+		return NewRTSPSource(path, width, height)
+
+	default:
+		return NewFileSource(path)
+	}
+}
+
+// DeviceSource reads from a local USB/CSI device via V4L2.
+type DeviceSource struct {
+	cap *gocv.VideoCapture
+}
+
+func NewDeviceSource(index, width, height int) (*DeviceSource, error) {
+	cap, err := gocv.OpenVideoCapture(index)
+	if err != nil {
+		return nil, fmt.Errorf("opening video capture device %v: %w", index, err)
+	}
+
+	cap.Set(gocv.VideoCaptureFrameWidth, float64(width))
+	cap.Set(gocv.VideoCaptureFrameHeight, float64(height))
+
+	return &DeviceSource{cap: cap}, nil
+}
+
+func (s *DeviceSource) Read(img *gocv.Mat) bool { return s.cap.Read(img) }
+
+func (s *DeviceSource) Info() (width, height, fps int) {
+	return int(s.cap.Get(gocv.VideoCaptureFrameWidth)),
+		int(s.cap.Get(gocv.VideoCaptureFrameHeight)),
+		int(s.cap.Get(gocv.VideoCaptureFPS))
+}
+
+func (s *DeviceSource) Close() error { return s.cap.Close() }
+
+// FileSource reads from a video file on disk.
+type FileSource struct {
+	cap *gocv.VideoCapture
+}
+
+func NewFileSource(path string) (*FileSource, error) {
+	cap, err := gocv.VideoCaptureFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening video file %v: %w", path, err)
+	}
+	return &FileSource{cap: cap}, nil
+}
+
+func (s *FileSource) Read(img *gocv.Mat) bool { return s.cap.Read(img) }
+
+func (s *FileSource) Info() (width, height, fps int) {
+	return int(s.cap.Get(gocv.VideoCaptureFrameWidth)),
+		int(s.cap.Get(gocv.VideoCaptureFrameHeight)),
+		int(s.cap.Get(gocv.VideoCaptureFPS))
+}
+
+func (s *FileSource) Close() error { return s.cap.Close() }
+
+const (
+	DefaultRTSPReconnectDelay = 1 * time.Second
+	MaxRTSPReconnectDelay     = 30 * time.Second
+)
+
+// RTSPSource reads from an IP camera over RTSP, reconnecting with an
+// exponential backoff whenever the stream drops.
+type RTSPSource struct {
+	url string
+
+	cap *gocv.VideoCapture
+
+	width, height int
+
+	readAt time.Time
+
+	reconnectDelay time.Duration
+}
+
+func NewRTSPSource(url string, width, height int) (*RTSPSource, error) {
+	s := &RTSPSource{
+		url:    url,
+		width:  width,
+		height: height,
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *RTSPSource) connect() error {
+	cap, err := gocv.OpenVideoCapture(s.url)
+	if err != nil {
+		return fmt.Errorf("opening rtsp stream %v: %w", s.url, err)
+	}
+
+	if s.width > 0 {
+		cap.Set(gocv.VideoCaptureFrameWidth, float64(s.width))
+	}
+	if s.height > 0 {
+		cap.Set(gocv.VideoCaptureFrameHeight, float64(s.height))
+	}
+
+	s.cap = cap
+	s.reconnectDelay = DefaultRTSPReconnectDelay
+	return nil
+}
+
+// Read reads the next frame, transparently reconnecting on failure. It
+// only returns false if reconnecting itself fails.
+func (s *RTSPSource) Read(img *gocv.Mat) bool {
+	delay := s.reconnectDelay
+	for {
+		if s.cap != nil && s.cap.Read(img) && !img.Empty() {
+			s.readAt = time.Now()
+			return true
+		}
+
+		log.Printf("rtsp stream %v dropped, reconnecting in %v", s.url, delay)
+
+		if s.cap != nil {
+			s.cap.Close()
+			s.cap = nil
+		}
+
+		time.Sleep(delay)
+
+		delay *= 2
+		if delay > MaxRTSPReconnectDelay {
+			delay = MaxRTSPReconnectDelay
+		}
+
+		if err := s.connect(); err != nil {
+			log.Print(err)
+			return false
+		}
+	}
+}
+
+func (s *RTSPSource) Timestamp() time.Time { return s.readAt }
+
+func (s *RTSPSource) Info() (width, height, fps int) {
+	if s.cap == nil {
+		return s.width, s.height, 0
+	}
+	return int(s.cap.Get(gocv.VideoCaptureFrameWidth)),
+		int(s.cap.Get(gocv.VideoCaptureFrameHeight)),
+		int(s.cap.Get(gocv.VideoCaptureFPS))
+}
+
+func (s *RTSPSource) Close() error {
+	if s.cap == nil {
+		return nil
+	}
+	return s.cap.Close()
+}